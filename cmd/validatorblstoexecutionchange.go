@@ -0,0 +1,196 @@
+// Copyright © 2023 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wealdtech/ethdo/grpc"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+var validatorBLSToExecutionChangeAccount string
+var validatorBLSToExecutionChangeKeystore string
+var validatorBLSToExecutionChangePassphrase string
+var validatorBLSToExecutionChangeValidatorIndex string
+var validatorBLSToExecutionChangeWithdrawalAddress string
+var validatorBLSToExecutionChangeForkVersion string
+var validatorBLSToExecutionChangeGenesisValidatorsRoot string
+
+var validatorBLSToExecutionChangeCmd = &cobra.Command{
+	Use:   "blstoexecutionchange",
+	Short: "Generate a signed BLSToExecutionChange message",
+	Long: `Generate a signed message to change a validator's withdrawal credentials from 0x00 BLS credentials
+to 0x01 execution credentials. For example:
+
+    ethdo validator blstoexecutionchange --account=primary/withdrawal --validatorindex=12345 --withdrawaladdress=0x0102030405060708090a0b0c0d0e0f1011121314 --forkversion=0x03000000
+
+--account is the path to the current BLS withdrawal account; alternatively --keystore (with --passphrase)
+names an EIP-2335 keystore file carrying the same key, allowing this command to be run without a wallet.
+
+The resulting JSON is suitable for submission to the /eth/v1/beacon/pool/bls_to_execution_changes endpoint
+of a beacon node.
+
+In quiet mode this will return 0 if the change can be generated correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorBLSToExecutionChangeAccount != "" || validatorBLSToExecutionChangeKeystore != "", "--account or --keystore is required")
+		assert(validatorBLSToExecutionChangeAccount == "" || validatorBLSToExecutionChangeKeystore == "", "--account and --keystore cannot be used together")
+
+		var account e2wtypes.Account
+		var keystoreKey e2types.PrivateKey
+		var fromPubKey e2types.PublicKey
+		if validatorBLSToExecutionChangeKeystore != "" {
+			var err error
+			keystoreKey, err = privateKeyFromKeystore(validatorBLSToExecutionChangeKeystore, validatorBLSToExecutionChangePassphrase)
+			errCheck(err, "Failed to decrypt --keystore")
+			fromPubKey = keystoreKey.PublicKey()
+		} else {
+			var err error
+			_, account, err = walletAndAccountFromPath(ctx, validatorBLSToExecutionChangeAccount)
+			errCheck(err, "Failed to obtain BLS withdrawal account")
+			fromPubKey, err = bestPublicKey(account)
+			errCheck(err, "Withdrawal account does not provide a public key")
+		}
+		outputIf(debug, fmt.Sprintf("BLS withdrawal public key is %#x", fromPubKey.Marshal()))
+
+		assert(validatorBLSToExecutionChangeWithdrawalAddress != "", "--withdrawaladdress is required")
+		toExecutionAddress, err := hex.DecodeString(strings.TrimPrefix(validatorBLSToExecutionChangeWithdrawalAddress, "0x"))
+		errCheck(err, "Invalid --withdrawaladdress")
+		assert(len(toExecutionAddress) == 20, "Execution address should be 20 bytes")
+
+		validatorIndex := validatorIndexFromInput(validatorBLSToExecutionChangeValidatorIndex)
+
+		forkVersion, genesisValidatorsRoot := capellaForkDataFromInput(validatorBLSToExecutionChangeForkVersion, validatorBLSToExecutionChangeGenesisValidatorsRoot)
+
+		blsToExecutionChange := struct {
+			ValidatorIndex     uint64
+			FromBLSPubKey      []byte `ssz-size:"48"`
+			ToExecutionAddress []byte `ssz-size:"20"`
+		}{
+			ValidatorIndex:     validatorIndex,
+			FromBLSPubKey:      fromPubKey.Marshal(),
+			ToExecutionAddress: toExecutionAddress,
+		}
+
+		domain := e2types.Domain(e2types.DomainBLSToExecutionChange, forkVersion, genesisValidatorsRoot)
+		var signature e2types.Signature
+		if keystoreKey != nil {
+			signingRoot, err := e2types.SigningRoot(blsToExecutionChange, domain)
+			errCheck(err, "Failed to generate signing root")
+			signature = keystoreKey.Sign(signingRoot[:])
+		} else {
+			signature, err = signStruct(account, blsToExecutionChange, domain)
+			errCheck(err, "Failed to sign BLS-to-execution-change message")
+		}
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		fmt.Printf(`{"message":{"validator_index":"%d","from_bls_pubkey":"%#x","to_execution_address":"%#x"},"signature":"%#x"}`+"\n",
+			blsToExecutionChange.ValidatorIndex, blsToExecutionChange.FromBLSPubKey, blsToExecutionChange.ToExecutionAddress, signature.Marshal())
+	},
+}
+
+// privateKeyFromKeystore decrypts an EIP-2335 keystore file and returns the BLS private key it protects,
+// using the same decode steps as "ethdo validator importkeystore".
+func privateKeyFromKeystore(file string, passphrase string) (e2types.PrivateKey, error) {
+	assert(passphrase != "", "--passphrase is required when using --keystore")
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	keystore := make(map[string]interface{})
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		return nil, err
+	}
+	cryptoFields, ok := keystore["crypto"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain a crypto section", file)
+	}
+
+	secretKeyBytes, err := keystorev4.New().Decrypt(cryptoFields, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return e2types.BLSPrivateKeyFromBytes(secretKeyBytes)
+}
+
+// validatorIndexFromInput parses a validator index from the --validatorindex flag. There is currently no
+// way to resolve a validator index from a public key via a connected beacon node, so it must be supplied
+// explicitly.
+func validatorIndexFromInput(input string) uint64 {
+	assert(input != "", "--validatorindex is required")
+	validatorIndex, err := strconv.ParseUint(input, 10, 64)
+	errCheck(err, "Invalid --validatorindex")
+	return validatorIndex
+}
+
+// capellaForkDataFromInput obtains the Capella fork version from --forkversion, and the genesis validators
+// root either from --genesisvalidatorsroot or, failing that, from a connected beacon node. Per EIP-7044,
+// BLSToExecutionChange messages are always domain-bound to CAPELLA_FORK_VERSION regardless of the chain's
+// current fork, so the genesis fork version (as used for DOMAIN_DEPOSIT) is not appropriate here; since
+// there is no way to fetch it automatically, --forkversion must always be supplied explicitly.
+func capellaForkDataFromInput(forkVersionInput string, genesisValidatorsRootInput string) ([]byte, []byte) {
+	assert(forkVersionInput != "", "--forkversion is required; the Capella fork version cannot currently be fetched automatically")
+	forkVersion, err := hex.DecodeString(strings.TrimPrefix(forkVersionInput, "0x"))
+	errCheck(err, "Invalid --forkversion")
+	assert(len(forkVersion) == 4, "Fork version must be exactly four bytes")
+
+	if genesisValidatorsRootInput != "" {
+		genesisValidatorsRoot, err := hex.DecodeString(strings.TrimPrefix(genesisValidatorsRootInput, "0x"))
+		errCheck(err, "Invalid --genesisvalidatorsroot")
+		assert(len(genesisValidatorsRoot) == 32, "Genesis validators root must be exactly 32 bytes")
+		return forkVersion, genesisValidatorsRoot
+	}
+
+	err = connect()
+	errCheck(err, "Failed to connect to beacon node")
+	config, err := grpc.FetchChainConfig(eth2GRPCConn)
+	if err != nil {
+		outputIf(!quiet, "Could not connect to beacon node; supply a connection with --connection or provide --genesisvalidatorsroot")
+		os.Exit(_exitFailure)
+	}
+	genesisValidatorsRootValue, exists := config["GenesisValidatorsRoot"]
+	assert(exists, "Failed to obtain genesis validators root")
+	return forkVersion, genesisValidatorsRootValue.([]byte)
+}
+
+func init() {
+	validatorCmd.AddCommand(validatorBLSToExecutionChangeCmd)
+	validatorFlags(validatorBLSToExecutionChangeCmd)
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeAccount, "account", "", "Account of the current BLS withdrawal account")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeKeystore, "keystore", "", "EIP-2335 keystore file carrying the current BLS withdrawal key, as an alternative to --account")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangePassphrase, "passphrase", "", "Passphrase to decrypt --keystore")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeValidatorIndex, "validatorindex", "", "Index of the validator")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeWithdrawalAddress, "withdrawaladdress", "", "Execution address to which the validator funds will be withdrawn")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeForkVersion, "forkversion", "", "The Capella fork version (required; cannot yet be fetched automatically)")
+	validatorBLSToExecutionChangeCmd.Flags().StringVar(&validatorBLSToExecutionChangeGenesisValidatorsRoot, "genesisvalidatorsroot", "", "Use a hard-coded genesis validators root (default is to fetch it from the node)")
+}
@@ -1,4 +1,4 @@
-// Copyright © 2019, 2020 Weald Technology Trading
+// Copyright © 2019, 2020, 2023 Weald Technology Trading
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
 // You may obtain a copy of the License at
@@ -16,10 +16,14 @@ package cmd
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/prysmaticlabs/go-ssz"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,13 +33,19 @@ import (
 	string2eth "github.com/wealdtech/go-string2eth"
 )
 
+// depositContractABI is the ABI of the deposit() function of the Ethereum deposit contract.
+const depositContractABI = `[{"inputs":[{"internalType":"bytes","name":"pubkey","type":"bytes"},{"internalType":"bytes","name":"withdrawal_credentials","type":"bytes"},{"internalType":"bytes","name":"signature","type":"bytes"},{"internalType":"bytes32","name":"deposit_data_root","type":"bytes32"}],"name":"deposit","outputs":[],"stateMutability":"payable","type":"function"}]`
+
 var validatorDepositDataValidatorAccount string
 var validatorDepositDataWithdrawalAccount string
 var validatorDepositDataWithdrawalPubKey string
+var validatorDepositDataWithdrawalAddress string
 var validatorDepositDataDepositValue string
 var validatorDepositDataRaw bool
 var validatorDepositDataForkVersion string
 var validatorDepositDataLaunchpad bool
+var validatorDepositDataOutDir string
+var validatorDepositDataClusterName string
 
 var validatorDepositDataCmd = &cobra.Command{
 	Use:   "depositdata",
@@ -46,8 +56,19 @@ var validatorDepositDataCmd = &cobra.Command{
 
 If validatoraccount is provided with an account path it will generate deposit data for all matching accounts.
 
+If withdrawaladdress is provided instead of withdrawalaccount or withdrawalpubkey the withdrawal credentials
+will be 0x01-prefixed execution withdrawal credentials pointing at that address, rather than 0x00-prefixed
+BLS withdrawal credentials.
+
 The information generated can be passed to ethereal to create a deposit from the Ethereum 1 chain.
 
+If --out-dir is supplied the deposit data for all matching validators is written, in the launchpad JSON
+schema regardless of --launchpad, to deposits.json in that directory, alongside a manifest.json containing
+the list of deposit data roots, their SHA256 Merkle root, the fork version, the withdrawal credentials and
+(if supplied) --cluster-name, so that the batch can be verified with "ethdo validator depositdata verify"
+before any funds move. --out-dir cannot be combined with --raw, since raw transaction calldata cannot be
+re-verified or re-submitted via the deposit send/verify commands.
+
 In quiet mode this will return 0 if the the data can be generated correctly, otherwise 1.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
@@ -65,9 +86,16 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 			outputIf(debug, fmt.Sprintf("Validator public key is %#x", pubKey.Marshal()))
 		}
 
-		assert(validatorDepositDataWithdrawalAccount != "" || validatorDepositDataWithdrawalPubKey != "", "--withdrawalaccount or --withdrawalpubkey is required")
+		assert(validatorDepositDataWithdrawalAccount != "" || validatorDepositDataWithdrawalPubKey != "" || validatorDepositDataWithdrawalAddress != "", "--withdrawalaccount, --withdrawalpubkey or --withdrawaladdress is required")
 		var withdrawalCredentials []byte
-		if validatorDepositDataWithdrawalAccount != "" {
+		if validatorDepositDataWithdrawalAddress != "" {
+			withdrawalAddressBytes, err := hex.DecodeString(strings.TrimPrefix(validatorDepositDataWithdrawalAddress, "0x"))
+			errCheck(err, "Invalid withdrawal address")
+			assert(len(withdrawalAddressBytes) == 20, "Execution address should be 20 bytes")
+			withdrawalCredentials = make([]byte, 32)
+			withdrawalCredentials[0] = byte(1) // ETH1_ADDRESS_WITHDRAWAL_PREFIX
+			copy(withdrawalCredentials[12:], withdrawalAddressBytes)
+		} else if validatorDepositDataWithdrawalAccount != "" {
 			_, withdrawalAccount, err := walletAndAccountFromPath(ctx, validatorDepositDataWithdrawalAccount)
 			errCheck(err, "Failed to obtain withdrawal account")
 			pubKey, err := bestPublicKey(withdrawalAccount)
@@ -75,6 +103,8 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 			outputIf(debug, fmt.Sprintf("Withdrawal public key is %#x", pubKey.Marshal()))
 			withdrawalCredentials = util.SHA256(pubKey.Marshal())
 			errCheck(err, "Failed to hash withdrawal credentials")
+			// This is hard-coded, to allow deposit data to be generated without a connection to the beacon node.
+			withdrawalCredentials[0] = byte(0) // BLS_WITHDRAWAL_PREFIX
 		} else {
 			withdrawalPubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(validatorDepositDataWithdrawalPubKey, "0x"))
 			errCheck(err, "Invalid withdrawal public key")
@@ -83,9 +113,9 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 			errCheck(err, "Value supplied with --withdrawalpubkey is not a valid public key")
 			withdrawalCredentials = util.SHA256(withdrawalPubKey.Marshal())
 			errCheck(err, "Failed to hash withdrawal credentials")
+			// This is hard-coded, to allow deposit data to be generated without a connection to the beacon node.
+			withdrawalCredentials[0] = byte(0) // BLS_WITHDRAWAL_PREFIX
 		}
-		// This is hard-coded, to allow deposit data to be generated without a connection to the beacon node.
-		withdrawalCredentials[0] = byte(0) // BLS_WITHDRAWAL_PREFIX
 		outputIf(debug, fmt.Sprintf("Withdrawal credentials are %#x", withdrawalCredentials))
 
 		assert(validatorDepositDataDepositValue != "", "--depositvalue is required")
@@ -94,8 +124,15 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 		// This is hard-coded, to allow deposit data to be generated without a connection to the beacon node.
 		assert(val >= 1000000000, "deposit value must be at least 1 Ether") // MIN_DEPOSIT_AMOUNT
 
+		if validatorDepositDataOutDir != "" {
+			assert(!validatorDepositDataRaw, "--raw cannot be used with --out-dir")
+		}
+
 		// For each key, generate deposit data
 		outputs := make([]string, 0)
+		launchpadOutputs := make([]string, 0, len(validatorAccounts))
+		depositDataRoots := make([][]byte, 0, len(validatorAccounts))
+		var lastForkVersion []byte
 		for _, validatorAccount := range validatorAccounts {
 			validatorPubKey, err := bestPublicKey(validatorAccount)
 			errCheck(err, "Validator account does not provide a public key")
@@ -156,48 +193,60 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 			depositDataRoot, err := ssz.HashTreeRoot(signedDepositData)
 			errCheck(err, "Failed to generate deposit data root")
 			outputIf(debug, fmt.Sprintf("Deposit data root is %x", depositDataRoot))
+			depositDataRoots = append(depositDataRoots, depositDataRoot[:])
+			lastForkVersion = forkVersion
+
+			depositMessage := struct {
+				PubKey                []byte `ssz-size:"48"`
+				WithdrawalCredentials []byte `ssz-size:"32"`
+				Value                 uint64
+			}{
+				PubKey:                validatorPubKey.Marshal(),
+				WithdrawalCredentials: withdrawalCredentials,
+				Value:                 val,
+			}
+			depositMessageRoot, err := ssz.HashTreeRoot(depositMessage)
+			errCheck(err, "Failed to generate deposit message root")
+			launchpadEntry := launchpadEntryJSON(signedDepositData.PubKey, signedDepositData.WithdrawalCredentials, val, signedDepositData.Signature, depositMessageRoot, depositDataRoot, forkVersion)
+			launchpadOutputs = append(launchpadOutputs, launchpadEntry)
 
 			switch {
 			case validatorDepositDataRaw:
-				// Build a raw transaction by hand
-				txData := []byte{0x22, 0x89, 0x51, 0x18}
-				// Pointer to validator public key
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}...)
-				// Pointer to withdrawal credentials
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0}...)
-				// Pointer to validator signature
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x20}...)
-				// Deposit data root
-				txData = append(txData, depositDataRoot[:]...)
-				// Validator public key (pad to 32-byte boundary)
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30}...)
-				txData = append(txData, validatorPubKey.Marshal()...)
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}...)
-				// Withdrawal credentials
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20}...)
-				txData = append(txData, withdrawalCredentials...)
-				// Deposit signature
-				txData = append(txData, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x60}...)
-				txData = append(txData, signedDepositData.Signature...)
+				txData, err := depositContractCalldata(validatorPubKey.Marshal(), withdrawalCredentials, signedDepositData.Signature, depositDataRoot)
+				errCheck(err, "Failed to build deposit contract calldata")
 				outputs = append(outputs, fmt.Sprintf("%#x", txData))
 			case validatorDepositDataLaunchpad:
-				depositMessage := struct {
-					PubKey                []byte `ssz-size:"48"`
-					WithdrawalCredentials []byte `ssz-size:"32"`
-					Value                 uint64
-				}{
-					PubKey:                validatorPubKey.Marshal(),
-					WithdrawalCredentials: withdrawalCredentials,
-					Value:                 val,
-				}
-				depositMessageRoot, err := ssz.HashTreeRoot(depositMessage)
-				errCheck(err, "Failed to generate deposit message root")
-				outputs = append(outputs, fmt.Sprintf(`[{"pubkey":"%x","withdrawal_credentials":"%x","amount":%d,"signature":"%x","deposit_message_root":"%x","deposit_data_root":"%x","fork_version":"%x"}]`, signedDepositData.PubKey, signedDepositData.WithdrawalCredentials, val, signedDepositData.Signature, depositMessageRoot, depositDataRoot, forkVersion))
+				outputs = append(outputs, fmt.Sprintf("[%s]", launchpadEntry))
 			default:
 				outputs = append(outputs, fmt.Sprintf(`{"name":"Deposit for %s","account":"%s","pubkey":"%#x","withdrawal_credentials":"%#x","signature":"%#x","value":%d,"deposit_data_root":"%#x","version":2}`, fmt.Sprintf("%s/%s", validatorWallet.Name(), validatorAccount.Name()), fmt.Sprintf("%s/%s", validatorWallet.Name(), validatorAccount.Name()), signedDepositData.PubKey, signedDepositData.WithdrawalCredentials, signedDepositData.Signature, val, depositDataRoot))
 			}
 		}
 
+		if validatorDepositDataOutDir != "" {
+			errCheck(os.MkdirAll(validatorDepositDataOutDir, 0700), "Failed to create --out-dir")
+
+			depositsJSON := fmt.Sprintf("[%s]", strings.Join(launchpadOutputs, ","))
+			depositsFilename := filepath.Join(validatorDepositDataOutDir, "deposits.json")
+			errCheck(ioutil.WriteFile(depositsFilename, []byte(depositsJSON), 0600), fmt.Sprintf("Failed to write %s", depositsFilename))
+
+			manifest := map[string]interface{}{
+				"deposit_data_roots":     hexEncodeAll(depositDataRoots),
+				"merkle_root":            fmt.Sprintf("%#x", merkleRoot(depositDataRoots)),
+				"fork_version":           fmt.Sprintf("%#x", lastForkVersion),
+				"withdrawal_credentials": fmt.Sprintf("%#x", withdrawalCredentials),
+			}
+			if validatorDepositDataClusterName != "" {
+				manifest["cluster_name"] = validatorDepositDataClusterName
+			}
+			manifestData, err := json.Marshal(manifest)
+			errCheck(err, "Failed to marshal manifest")
+			manifestFilename := filepath.Join(validatorDepositDataOutDir, "manifest.json")
+			errCheck(ioutil.WriteFile(manifestFilename, manifestData, 0600), fmt.Sprintf("Failed to write %s", manifestFilename))
+
+			outputIf(!quiet, fmt.Sprintf("Wrote %s and %s", depositsFilename, manifestFilename))
+			os.Exit(0)
+		}
+
 		if quiet {
 			os.Exit(0)
 		}
@@ -212,14 +261,62 @@ In quiet mode this will return 0 if the the data can be generated correctly, oth
 	},
 }
 
+// launchpadEntryJSON renders a single deposit data entry in the schema produced by the Ethereum Launchpad,
+// as used by "ethdo validator depositdata --launchpad" and "ethdo validator exportkeystore".
+func launchpadEntryJSON(pubKey []byte, withdrawalCredentials []byte, amount uint64, signature []byte, depositMessageRoot [32]byte, depositDataRoot [32]byte, forkVersion []byte) string {
+	return fmt.Sprintf(`{"pubkey":"%x","withdrawal_credentials":"%x","amount":%d,"signature":"%x","deposit_message_root":"%x","deposit_data_root":"%x","fork_version":"%x"}`,
+		pubKey, withdrawalCredentials, amount, signature, depositMessageRoot, depositDataRoot, forkVersion)
+}
+
+// hexEncodeAll renders a list of byte slices as 0x-prefixed hex strings.
+func hexEncodeAll(values [][]byte) []string {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = fmt.Sprintf("%#x", value)
+	}
+	return encoded
+}
+
+// merkleRoot computes the SHA256 Merkle root over a list of leaves, duplicating the final leaf at each
+// level when the number of nodes is odd.
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return util.SHA256([]byte{})
+	}
+	nodes := leaves
+	for len(nodes) > 1 {
+		if len(nodes)%2 == 1 {
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
+		next := make([][]byte, 0, len(nodes)/2)
+		for i := 0; i < len(nodes); i += 2 {
+			next = append(next, util.SHA256(append(append([]byte{}, nodes[i]...), nodes[i+1]...)))
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
+
+// depositContractCalldata ABI-encodes a call to the deposit contract's deposit() function.
+func depositContractCalldata(pubKey []byte, withdrawalCredentials []byte, signature []byte, depositDataRoot [32]byte) ([]byte, error) {
+	depositABI, err := abi.JSON(strings.NewReader(depositContractABI))
+	if err != nil {
+		return nil, err
+	}
+	return depositABI.Pack("deposit", pubKey, withdrawalCredentials, signature, depositDataRoot)
+}
+
 func init() {
 	validatorCmd.AddCommand(validatorDepositDataCmd)
 	validatorFlags(validatorDepositDataCmd)
 	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataValidatorAccount, "validatoraccount", "", "Account of the account carrying out the validation")
 	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataWithdrawalAccount, "withdrawalaccount", "", "Account of the account to which the validator funds will be withdrawn")
 	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataWithdrawalPubKey, "withdrawalpubkey", "", "Public key of the account to which the validator funds will be withdrawn")
+	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataWithdrawalAddress, "withdrawaladdress", "", "Execution address to which the validator funds will be withdrawn (0x01 withdrawal credentials)")
 	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataDepositValue, "depositvalue", "", "Value of the amount to be deposited")
 	validatorDepositDataCmd.Flags().BoolVar(&validatorDepositDataRaw, "raw", false, "Print raw deposit data transaction data")
 	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataForkVersion, "forkversion", "", "Use a hard-coded fork version (default is to fetch it from the node)")
 	validatorDepositDataCmd.Flags().BoolVar(&validatorDepositDataLaunchpad, "launchpad", false, "Print launchpad-compatible JSON")
+	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataOutDir, "out-dir", "", "Directory to which to write deposits.json and manifest.json for a batch of validators")
+	validatorDepositDataCmd.Flags().StringVar(&validatorDepositDataClusterName, "cluster-name", "", "Label for the cluster, recorded in manifest.json")
 }
@@ -0,0 +1,114 @@
+// Copyright © 2022 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	util "github.com/wealdtech/go-eth2-util"
+)
+
+func TestMerkleRootSingleLeaf(t *testing.T) {
+	leaf := util.SHA256([]byte("leaf"))
+	root := merkleRoot([][]byte{leaf})
+	if string(root) != string(leaf) {
+		t.Errorf("single-leaf Merkle root should equal the leaf itself")
+	}
+}
+
+func TestMerkleRootPair(t *testing.T) {
+	leafA := util.SHA256([]byte("a"))
+	leafB := util.SHA256([]byte("b"))
+	expected := util.SHA256(append(append([]byte{}, leafA...), leafB...))
+
+	root := merkleRoot([][]byte{leafA, leafB})
+	if string(root) != string(expected) {
+		t.Errorf("pair Merkle root did not match manual hash")
+	}
+}
+
+func TestMerkleRootOddCountDuplicatesLastLeaf(t *testing.T) {
+	leafA := util.SHA256([]byte("a"))
+	leafB := util.SHA256([]byte("b"))
+	leafC := util.SHA256([]byte("c"))
+
+	rootWithDuplicate := merkleRoot([][]byte{leafA, leafB, leafC, leafC})
+	rootOdd := merkleRoot([][]byte{leafA, leafB, leafC})
+	if string(rootOdd) != string(rootWithDuplicate) {
+		t.Errorf("odd-length Merkle root should duplicate the final leaf at each level")
+	}
+}
+
+func TestHexEncodeAll(t *testing.T) {
+	values := [][]byte{{0x01, 0x02}, {0xab}}
+	encoded := hexEncodeAll(values)
+	if len(encoded) != 2 || encoded[0] != "0x0102" || encoded[1] != "0xab" {
+		t.Errorf("unexpected hex encoding: %v", encoded)
+	}
+}
+
+func TestDepositContractCalldata(t *testing.T) {
+	pubKey := bytes.Repeat([]byte{0x01}, 48)
+	withdrawalCredentials := bytes.Repeat([]byte{0x02}, 32)
+	signature := bytes.Repeat([]byte{0x03}, 96)
+	var depositDataRoot [32]byte
+	copy(depositDataRoot[:], bytes.Repeat([]byte{0x04}, 32))
+
+	calldata, err := depositContractCalldata(pubKey, withdrawalCredentials, signature, depositDataRoot)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	depositABI, err := abi.JSON(strings.NewReader(depositContractABI))
+	if err != nil {
+		t.Fatalf("unexpected error parsing ABI: %v", err)
+	}
+	method, exists := depositABI.Methods["deposit"]
+	if !exists {
+		t.Fatalf("deposit method not found in ABI")
+	}
+	if !bytes.Equal(calldata[:4], method.ID) {
+		t.Errorf("calldata does not start with the deposit() method selector")
+	}
+
+	var decoded struct {
+		PubKey                []byte
+		WithdrawalCredentials []byte
+		Signature             []byte
+		DepositDataRoot       [32]byte
+	}
+	values, err := method.Inputs.Unpack(calldata[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack calldata: %v", err)
+	}
+	decoded.PubKey, _ = values[0].([]byte)
+	decoded.WithdrawalCredentials, _ = values[1].([]byte)
+	decoded.Signature, _ = values[2].([]byte)
+	decoded.DepositDataRoot, _ = values[3].([32]byte)
+	if !bytes.Equal(decoded.PubKey, pubKey) {
+		t.Errorf("decoded public key does not match")
+	}
+	if !bytes.Equal(decoded.WithdrawalCredentials, withdrawalCredentials) {
+		t.Errorf("decoded withdrawal credentials do not match")
+	}
+	if !bytes.Equal(decoded.Signature, signature) {
+		t.Errorf("decoded signature does not match")
+	}
+	if decoded.DepositDataRoot != depositDataRoot {
+		t.Errorf("decoded deposit data root does not match")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © 2023 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/prysmaticlabs/go-ssz"
+	"github.com/spf13/cobra"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+var validatorDepositDataVerifyFile string
+var validatorDepositDataVerifyWithdrawalCredentials string
+var validatorDepositDataVerifyForkVersion string
+
+var validatorDepositDataVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify deposit data generated by ethdo validator depositdata",
+	Long: `Verify every entry in a deposits.json file before submitting it on-chain. For example:
+
+    ethdo validator depositdata verify --file=deposits.json --withdrawalcredentials=0x... --forkversion=0x...
+
+Each entry's BLS signature is verified against its deposit message, the deposit_message_root and
+deposit_data_root are recomputed and compared against the values in the file, and (if supplied)
+--withdrawalcredentials and --forkversion are checked against every entry.
+
+In quiet mode this will return 0 if every entry verifies correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assert(validatorDepositDataVerifyFile != "", "--file is required")
+		data, err := ioutil.ReadFile(validatorDepositDataVerifyFile)
+		errCheck(err, "Failed to read --file")
+
+		var entries []struct {
+			PubKey                string `json:"pubkey"`
+			WithdrawalCredentials string `json:"withdrawal_credentials"`
+			Amount                uint64 `json:"amount"`
+			Signature             string `json:"signature"`
+			DepositMessageRoot    string `json:"deposit_message_root"`
+			DepositDataRoot       string `json:"deposit_data_root"`
+			ForkVersion           string `json:"fork_version"`
+		}
+		errCheck(json.Unmarshal(data, &entries), "Failed to parse --file")
+		assert(len(entries) > 0, "--file does not contain any deposit data")
+
+		var expectedWithdrawalCredentials []byte
+		if validatorDepositDataVerifyWithdrawalCredentials != "" {
+			expectedWithdrawalCredentials, err = hex.DecodeString(strings.TrimPrefix(validatorDepositDataVerifyWithdrawalCredentials, "0x"))
+			errCheck(err, "Invalid --withdrawalcredentials")
+		}
+		var expectedForkVersion []byte
+		if validatorDepositDataVerifyForkVersion != "" {
+			expectedForkVersion, err = hex.DecodeString(strings.TrimPrefix(validatorDepositDataVerifyForkVersion, "0x"))
+			errCheck(err, "Invalid --forkversion")
+		}
+
+		for i, entry := range entries {
+			pubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(entry.PubKey, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid public key", i))
+			withdrawalCredentials, err := hex.DecodeString(strings.TrimPrefix(entry.WithdrawalCredentials, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid withdrawal credentials", i))
+			signatureBytes, err := hex.DecodeString(strings.TrimPrefix(entry.Signature, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid signature", i))
+			forkVersion, err := hex.DecodeString(strings.TrimPrefix(entry.ForkVersion, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid fork version", i))
+
+			if expectedWithdrawalCredentials != nil {
+				assert(string(withdrawalCredentials) == string(expectedWithdrawalCredentials), fmt.Sprintf("Entry %d: withdrawal credentials do not match --withdrawalcredentials", i))
+			}
+			if expectedForkVersion != nil {
+				assert(string(forkVersion) == string(expectedForkVersion), fmt.Sprintf("Entry %d: fork version does not match --forkversion", i))
+			}
+
+			depositMessage := struct {
+				PubKey                []byte `ssz-size:"48"`
+				WithdrawalCredentials []byte `ssz-size:"32"`
+				Value                 uint64
+			}{
+				PubKey:                pubKeyBytes,
+				WithdrawalCredentials: withdrawalCredentials,
+				Value:                 entry.Amount,
+			}
+			depositMessageRoot, err := ssz.HashTreeRoot(depositMessage)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to generate deposit message root", i))
+			assert(fmt.Sprintf("%x", depositMessageRoot) == strings.TrimPrefix(entry.DepositMessageRoot, "0x"), fmt.Sprintf("Entry %d: deposit message root does not match", i))
+
+			signedDepositData := struct {
+				PubKey                []byte `ssz-size:"48"`
+				WithdrawalCredentials []byte `ssz-size:"32"`
+				Value                 uint64
+				Signature             []byte `ssz-size:"96"`
+			}{
+				PubKey:                pubKeyBytes,
+				WithdrawalCredentials: withdrawalCredentials,
+				Value:                 entry.Amount,
+				Signature:             signatureBytes,
+			}
+			depositDataRoot, err := ssz.HashTreeRoot(signedDepositData)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to generate deposit data root", i))
+			assert(fmt.Sprintf("%x", depositDataRoot) == strings.TrimPrefix(entry.DepositDataRoot, "0x"), fmt.Sprintf("Entry %d: deposit data root does not match", i))
+
+			pubKey, err := e2types.BLSPublicKeyFromBytes(pubKeyBytes)
+			errCheck(err, fmt.Sprintf("Entry %d: invalid public key", i))
+			signature, err := e2types.BLSSignatureFromBytes(signatureBytes)
+			errCheck(err, fmt.Sprintf("Entry %d: invalid signature", i))
+			domain := e2types.Domain(e2types.DomainDeposit, forkVersion, e2types.ZeroGenesisValidatorsRoot)
+			signingRoot, err := e2types.SigningRoot(depositMessage, domain)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to generate signing root", i))
+			assert(signature.Verify(signingRoot[:], pubKey), fmt.Sprintf("Entry %d: signature does not verify", i))
+
+			outputIf(verbose, fmt.Sprintf("Entry %d (%s) verified", i, entry.PubKey))
+		}
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		outputIf(!quiet, fmt.Sprintf("Verified %d deposit data entries", len(entries)))
+	},
+}
+
+func init() {
+	validatorDepositDataCmd.AddCommand(validatorDepositDataVerifyCmd)
+	validatorDepositDataVerifyCmd.Flags().StringVar(&validatorDepositDataVerifyFile, "file", "", "Deposit data file to verify")
+	validatorDepositDataVerifyCmd.Flags().StringVar(&validatorDepositDataVerifyWithdrawalCredentials, "withdrawalcredentials", "", "Expected withdrawal credentials for every entry")
+	validatorDepositDataVerifyCmd.Flags().StringVar(&validatorDepositDataVerifyForkVersion, "forkversion", "", "Expected fork version for every entry")
+}
@@ -0,0 +1,186 @@
+// Copyright © 2022 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// depositTransactionGasLimit is comfortably above the gas used by a deposit contract call.
+const depositTransactionGasLimit = 200000
+
+var validatorDepositSendConnection string
+var validatorDepositSendFrom string
+var validatorDepositSendPassphrase string
+var validatorDepositSendDepositContract string
+var validatorDepositSendFile string
+var validatorDepositSendMinDelay time.Duration
+
+var validatorDepositSendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Submit deposits to the deposit contract",
+	Long: `Submit one transaction per validator in a deposits.json file directly to the deposit contract.
+For example:
+
+    ethdo validator deposit send --connection=https://localhost:8545 --from=0x... --depositcontract=0x... --file=deposits.json --min-delay=2s
+
+--from is either the hex-encoded private key of the account paying for the deposits, or the path to a
+go-ethereum keystore file for that account, in which case --passphrase is also required. --min-delay is the
+minimum time to wait between submitting consecutive deposits, to work around nonce and propagation issues.
+
+In quiet mode this will return 0 if every deposit is submitted correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorDepositSendConnection != "", "--connection is required")
+		assert(validatorDepositSendFrom != "", "--from is required")
+		assert(validatorDepositSendDepositContract != "", "--depositcontract is required")
+		assert(validatorDepositSendFile != "", "--file is required")
+
+		client, err := ethclient.DialContext(ctx, validatorDepositSendConnection)
+		errCheck(err, "Failed to connect to execution node")
+
+		privateKey, err := fromPrivateKey(validatorDepositSendFrom, validatorDepositSendPassphrase)
+		errCheck(err, "Invalid --from")
+		fromAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+		depositContractAddress := common.HexToAddress(validatorDepositSendDepositContract)
+
+		data, err := ioutil.ReadFile(validatorDepositSendFile)
+		errCheck(err, "Failed to read --file")
+		var entries []struct {
+			PubKey                string `json:"pubkey"`
+			WithdrawalCredentials string `json:"withdrawal_credentials"`
+			Amount                uint64 `json:"amount"`
+			Signature             string `json:"signature"`
+			DepositDataRoot       string `json:"deposit_data_root"`
+		}
+		errCheck(json.Unmarshal(data, &entries), "Failed to parse --file")
+		assert(len(entries) > 0, "--file does not contain any deposit data")
+
+		chainID, err := client.ChainID(ctx)
+		errCheck(err, "Failed to obtain chain ID")
+
+		nonce, err := client.PendingNonceAt(ctx, fromAddress)
+		errCheck(err, "Failed to obtain nonce")
+
+		for i, entry := range entries {
+			pubKey, err := hex.DecodeString(strings.TrimPrefix(entry.PubKey, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid public key", i))
+			withdrawalCredentials, err := hex.DecodeString(strings.TrimPrefix(entry.WithdrawalCredentials, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid withdrawal credentials", i))
+			signature, err := hex.DecodeString(strings.TrimPrefix(entry.Signature, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid signature", i))
+			depositDataRootBytes, err := hex.DecodeString(strings.TrimPrefix(entry.DepositDataRoot, "0x"))
+			errCheck(err, fmt.Sprintf("Entry %d: invalid deposit data root", i))
+			assert(len(depositDataRootBytes) == 32, fmt.Sprintf("Entry %d: deposit data root should be 32 bytes", i))
+			var depositDataRoot [32]byte
+			copy(depositDataRoot[:], depositDataRootBytes)
+
+			calldata, err := depositContractCalldata(pubKey, withdrawalCredentials, signature, depositDataRoot)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to build deposit contract calldata", i))
+
+			amountWei := new(big.Int).Mul(big.NewInt(int64(entry.Amount)), big.NewInt(1000000000))
+
+			gasPrice, err := client.SuggestGasPrice(ctx)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to suggest gas price", i))
+
+			tx, err := sendDepositTransaction(ctx, client, privateKey, chainID, nonce, depositContractAddress, gasPrice, amountWei, calldata)
+			errCheck(err, fmt.Sprintf("Entry %d: failed to send deposit transaction", i))
+			nonce++
+
+			outputIf(!quiet, fmt.Sprintf("Submitted deposit for %s in transaction %s", entry.PubKey, tx.Hash().Hex()))
+
+			if i < len(entries)-1 && validatorDepositSendMinDelay > 0 {
+				time.Sleep(validatorDepositSendMinDelay)
+			}
+		}
+
+		if quiet {
+			os.Exit(0)
+		}
+	},
+}
+
+// fromPrivateKey resolves the private key of the paying account, either from a hex-encoded private key or,
+// if --from names an existing file, from a go-ethereum keystore decrypted with passphrase.
+func fromPrivateKey(from string, passphrase string) (*ecdsa.PrivateKey, error) {
+	if _, err := os.Stat(from); err == nil {
+		data, err := ioutil.ReadFile(from)
+		if err != nil {
+			return nil, err
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("--passphrase is required when --from is a keystore")
+		}
+		key, err := keystore.DecryptKey(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return key.PrivateKey, nil
+	}
+
+	return crypto.HexToECDSA(strings.TrimPrefix(from, "0x"))
+}
+
+// sendDepositTransaction builds, signs and submits a single deposit contract call.
+func sendDepositTransaction(ctx context.Context, client *ethclient.Client, privateKey *ecdsa.PrivateKey, chainID *big.Int, nonce uint64, to common.Address, gasPrice *big.Int, value *big.Int, calldata []byte) (*types.Transaction, error) {
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    value,
+		Gas:      depositTransactionGasLimit,
+		GasPrice: gasPrice,
+		Data:     calldata,
+	})
+
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
+	}
+
+	return signedTx, nil
+}
+
+func init() {
+	validatorDepositCmd.AddCommand(validatorDepositSendCmd)
+	validatorDepositSendCmd.Flags().StringVar(&validatorDepositSendConnection, "connection", "", "Execution layer RPC endpoint to which to submit the deposits")
+	validatorDepositSendCmd.Flags().StringVar(&validatorDepositSendFrom, "from", "", "Hex-encoded private key, or keystore file path, of the account paying for the deposits")
+	validatorDepositSendCmd.Flags().StringVar(&validatorDepositSendPassphrase, "passphrase", "", "Passphrase to decrypt --from when it is a keystore file")
+	validatorDepositSendCmd.Flags().StringVar(&validatorDepositSendDepositContract, "depositcontract", "", "Address of the deposit contract")
+	validatorDepositSendCmd.Flags().StringVar(&validatorDepositSendFile, "file", "", "Deposit data file to submit")
+	validatorDepositSendCmd.Flags().DurationVar(&validatorDepositSendMinDelay, "min-delay", 0, "Minimum time to wait between submitting consecutive deposits")
+}
@@ -0,0 +1,103 @@
+// Copyright © 2024 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestFromPrivateKeyHexEncoded(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	privateKey, err := fromPrivateKey("0x"+hex.EncodeToString(crypto.FromECDSA(key)), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSA(privateKey), crypto.FromECDSA(key)) {
+		t.Errorf("decoded private key does not match")
+	}
+}
+
+func TestFromPrivateKeyKeystoreFile(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "ethdo-test")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := keystore.EncryptKey(&keystore.Key{
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting key: %v", err)
+	}
+	filename := filepath.Join(dir, "keystore.json")
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatalf("unexpected error writing keystore: %v", err)
+	}
+
+	privateKey, err := fromPrivateKey(filename, "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSA(privateKey), crypto.FromECDSA(key)) {
+		t.Errorf("decrypted private key does not match")
+	}
+}
+
+func TestFromPrivateKeyKeystoreFileMissingPassphrase(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "ethdo-test")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := keystore.EncryptKey(&keystore.Key{
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting key: %v", err)
+	}
+	filename := filepath.Join(dir, "keystore.json")
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatalf("unexpected error writing keystore: %v", err)
+	}
+
+	if _, err := fromPrivateKey(filename, ""); err == nil {
+		t.Errorf("expected an error when --passphrase is missing for a keystore file")
+	}
+}
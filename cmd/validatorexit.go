@@ -0,0 +1,163 @@
+// Copyright © 2020 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wealdtech/ethdo/grpc"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+var validatorExitValidatorAccount string
+var validatorExitKeystore string
+var validatorExitPassphrase string
+var validatorExitValidatorIndex string
+var validatorExitEpoch string
+var validatorExitForkVersion string
+var validatorExitGenesisValidatorsRoot string
+
+var validatorExitCmd = &cobra.Command{
+	Use:   "exit",
+	Short: "Generate a signed voluntary exit for a validator",
+	Long: `Generate a signed message to exit a validator from the beacon chain. For example:
+
+    ethdo validator exit --validatoraccount=primary/validator --validatorindex=12345 --epoch=163249 --forkversion=0x00000000
+
+--validatoraccount is the path to the validator account; alternatively --keystore (with --passphrase) names
+an EIP-2335 keystore file carrying the same key, allowing this command to be run without a wallet.
+--forkversion is required; it cannot yet be fetched automatically. --genesisvalidatorsroot is fetched from
+a connected beacon node unless supplied, allowing this command to be run without network access once it is
+known.
+
+The resulting JSON is suitable for submission to the /eth/v1/beacon/pool/voluntary_exits endpoint of a
+beacon node.
+
+In quiet mode this will return 0 if the exit can be generated correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorExitValidatorAccount != "" || validatorExitKeystore != "", "--validatoraccount or --keystore is required")
+		assert(validatorExitValidatorAccount == "" || validatorExitKeystore == "", "--validatoraccount and --keystore cannot be used together")
+
+		var validatorAccount e2wtypes.Account
+		var keystoreKey e2types.PrivateKey
+		if validatorExitKeystore != "" {
+			var err error
+			keystoreKey, err = privateKeyFromKeystore(validatorExitKeystore, validatorExitPassphrase)
+			errCheck(err, "Failed to decrypt --keystore")
+		} else {
+			var err error
+			_, validatorAccount, err = walletAndAccountFromPath(ctx, validatorExitValidatorAccount)
+			errCheck(err, "Failed to obtain validator account")
+			pubKey, err := bestPublicKey(validatorAccount)
+			errCheck(err, "Validator account does not provide a public key")
+			outputIf(debug, fmt.Sprintf("Validator public key is %#x", pubKey.Marshal()))
+		}
+
+		validatorIndex := validatorIndexFromInput(validatorExitValidatorIndex)
+
+		epoch := epochFromInput(validatorExitEpoch)
+
+		forkVersion, genesisValidatorsRoot := currentForkDataFromInput(validatorExitForkVersion, validatorExitGenesisValidatorsRoot)
+
+		voluntaryExit := struct {
+			Epoch          uint64
+			ValidatorIndex uint64
+		}{
+			Epoch:          epoch,
+			ValidatorIndex: validatorIndex,
+		}
+		outputIf(debug, fmt.Sprintf("Voluntary exit:\n\tEpoch: %d\n\tValidator index: %d", voluntaryExit.Epoch, voluntaryExit.ValidatorIndex))
+
+		domain := e2types.Domain(e2types.DomainVoluntaryExit, forkVersion, genesisValidatorsRoot)
+		var signature e2types.Signature
+		var err error
+		if keystoreKey != nil {
+			signingRoot, err := e2types.SigningRoot(voluntaryExit, domain)
+			errCheck(err, "Failed to generate signing root")
+			signature = keystoreKey.Sign(signingRoot[:])
+		} else {
+			signature, err = signStruct(validatorAccount, voluntaryExit, domain)
+			errCheck(err, "Failed to sign voluntary exit")
+		}
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		fmt.Printf(`{"message":{"epoch":"%d","validator_index":"%d"},"signature":"%#x"}`+"\n",
+			voluntaryExit.Epoch, voluntaryExit.ValidatorIndex, signature.Marshal())
+	},
+}
+
+// epochFromInput parses an epoch from the --epoch flag. There is currently no way to fetch the current
+// epoch from a connected beacon node, so it must be supplied explicitly.
+func epochFromInput(input string) uint64 {
+	assert(input != "", "--epoch is required")
+	epoch, err := strconv.ParseUint(input, 10, 64)
+	errCheck(err, "Invalid --epoch")
+	return epoch
+}
+
+// currentForkDataFromInput obtains the fork version active at the exit epoch from --forkversion, and the
+// genesis validators root either from --genesisvalidatorsroot or, failing that, from a connected beacon
+// node. Per get_domain(state, DOMAIN_VOLUNTARY_EXIT, epoch), this must be the fork version active at the
+// exit epoch (state.fork.current_version), not the chain's genesis fork version; since there is no way
+// to fetch that automatically, --forkversion must always be supplied explicitly.
+func currentForkDataFromInput(forkVersionInput string, genesisValidatorsRootInput string) ([]byte, []byte) {
+	assert(forkVersionInput != "", "--forkversion is required; the fork version active at the exit epoch cannot currently be fetched automatically")
+	forkVersion, err := hex.DecodeString(strings.TrimPrefix(forkVersionInput, "0x"))
+	errCheck(err, "Invalid --forkversion")
+	assert(len(forkVersion) == 4, "Fork version must be exactly four bytes")
+
+	if genesisValidatorsRootInput != "" {
+		genesisValidatorsRoot, err := hex.DecodeString(strings.TrimPrefix(genesisValidatorsRootInput, "0x"))
+		errCheck(err, "Invalid --genesisvalidatorsroot")
+		assert(len(genesisValidatorsRoot) == 32, "Genesis validators root must be exactly 32 bytes")
+		return forkVersion, genesisValidatorsRoot
+	}
+
+	err = connect()
+	errCheck(err, "Failed to connect to beacon node")
+	config, err := grpc.FetchChainConfig(eth2GRPCConn)
+	if err != nil {
+		outputIf(!quiet, "Could not connect to beacon node; supply a connection with --connection or provide --genesisvalidatorsroot")
+		os.Exit(_exitFailure)
+	}
+	genesisValidatorsRootValue, exists := config["GenesisValidatorsRoot"]
+	assert(exists, "Failed to obtain genesis validators root")
+	return forkVersion, genesisValidatorsRootValue.([]byte)
+}
+
+func init() {
+	validatorCmd.AddCommand(validatorExitCmd)
+	validatorFlags(validatorExitCmd)
+	validatorExitCmd.Flags().StringVar(&validatorExitValidatorAccount, "validatoraccount", "", "Account of the validator to exit")
+	validatorExitCmd.Flags().StringVar(&validatorExitKeystore, "keystore", "", "EIP-2335 keystore file carrying the validator key, as an alternative to --validatoraccount")
+	validatorExitCmd.Flags().StringVar(&validatorExitPassphrase, "passphrase", "", "Passphrase to decrypt --keystore")
+	validatorExitCmd.Flags().StringVar(&validatorExitValidatorIndex, "validatorindex", "", "Index of the validator")
+	validatorExitCmd.Flags().StringVar(&validatorExitEpoch, "epoch", "", "Epoch at which the exit takes place")
+	validatorExitCmd.Flags().StringVar(&validatorExitForkVersion, "forkversion", "", "The fork version active at the exit epoch (required; cannot yet be fetched automatically)")
+	validatorExitCmd.Flags().StringVar(&validatorExitGenesisValidatorsRoot, "genesisvalidatorsroot", "", "Use a hard-coded genesis validators root (default is to fetch it from the node)")
+}
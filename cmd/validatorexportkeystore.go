@@ -0,0 +1,255 @@
+// Copyright © 2021 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prysmaticlabs/go-ssz"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wealdtech/ethdo/grpc"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	util "github.com/wealdtech/go-eth2-util"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+	string2eth "github.com/wealdtech/go-string2eth"
+)
+
+var validatorExportKeystoreValidatorAccount string
+var validatorExportKeystoreWithdrawalAccount string
+var validatorExportKeystoreWithdrawalPubKey string
+var validatorExportKeystoreDepositValue string
+var validatorExportKeystoreForkVersion string
+var validatorExportKeystoreKDF string
+var validatorExportKeystorePassphrase string
+var validatorExportKeystoreOutDir string
+
+var validatorExportKeystoreCmd = &cobra.Command{
+	Use:   "exportkeystore",
+	Short: "Export validator accounts as EIP-2335 keystores",
+	Long: `Export one or more validator accounts as EIP-2335 keystores, alongside a companion
+Launchpad-format deposit data file. For example:
+
+    ethdo validator exportkeystore --validatoraccount=primary/validator --withdrawalaccount=primary/current --depositvalue="32 Ether" --passphrase=secret --out-dir=./export
+
+Each account is written to its own keystore-<path>-<timestamp>.json file in --out-dir, where <path> is the
+account's real EIP-2334 derivation path for HD wallets or the keystore's UUID for accounts with no fixed
+path (e.g. non-deterministic wallets), and the deposit data for the full set of exported accounts is
+written to deposit_data-<timestamp>.json in the same directory, in the schema produced by
+"ethdo validator depositdata --launchpad".
+
+In quiet mode this will return 0 if the keystores can be exported correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorExportKeystoreValidatorAccount != "", "--validatoraccount is required")
+		assert(validatorExportKeystorePassphrase != "", "--passphrase is required")
+		assert(validatorExportKeystoreOutDir != "", "--out-dir is required")
+
+		validatorWallet, validatorAccounts, err := walletAndAccountsFromPath(ctx, validatorExportKeystoreValidatorAccount)
+		errCheck(err, "Failed to obtain validator accounts")
+		assert(len(validatorAccounts) > 0, "Failed to obtain validator account")
+
+		withdrawalCredentials, err := withdrawalCredentialsFromInput(ctx, validatorExportKeystoreWithdrawalAccount, validatorExportKeystoreWithdrawalPubKey)
+		errCheck(err, "Failed to obtain withdrawal credentials")
+
+		assert(validatorExportKeystoreDepositValue != "", "--depositvalue is required")
+		val, err := string2eth.StringToGWei(validatorExportKeystoreDepositValue)
+		errCheck(err, "Invalid value")
+		assert(val >= 1000000000, "deposit value must be at least 1 Ether") // MIN_DEPOSIT_AMOUNT
+
+		forkVersion, err := forkVersionFromInput(validatorExportKeystoreForkVersion)
+		errCheck(err, "Failed to obtain fork version")
+
+		errCheck(os.MkdirAll(validatorExportKeystoreOutDir, 0700), "Failed to create --out-dir")
+
+		encryptor, err := exportKeystoreEncryptor(validatorExportKeystoreKDF)
+		errCheck(err, "Invalid --kdf")
+
+		timestamp := time.Now().Unix()
+
+		depositDataOutputs := make([]string, 0, len(validatorAccounts))
+		for _, validatorAccount := range validatorAccounts {
+			outputIf(verbose, fmt.Sprintf("Exporting keystore for %s/%s", validatorWallet.Name(), validatorAccount.Name()))
+
+			privateKeyProvider, isPrivateKeyProvider := validatorAccount.(e2wtypes.AccountPrivateKeyProvider)
+			assert(isPrivateKeyProvider, "Validator account does not provide access to its private key")
+			privateKey, err := privateKeyProvider.PrivateKey(ctx)
+			errCheck(err, "Failed to obtain validator account private key")
+
+			cryptoFields, err := encryptor.Encrypt(privateKey.Marshal(), validatorExportKeystorePassphrase)
+			errCheck(err, "Failed to encrypt validator private key")
+
+			path := ""
+			if pathProvider, isPathProvider := validatorAccount.(e2wtypes.AccountPathProvider); isPathProvider {
+				path = pathProvider.Path()
+			}
+
+			keystoreUUID := uuid.New().String()
+			keystore := map[string]interface{}{
+				"crypto":      cryptoFields,
+				"description": fmt.Sprintf("%s/%s", validatorWallet.Name(), validatorAccount.Name()),
+				"pubkey":      fmt.Sprintf("%x", privateKey.PublicKey().Marshal()),
+				"path":        path,
+				"uuid":        keystoreUUID,
+				"version":     encryptor.Version(),
+			}
+			data, err := json.Marshal(keystore)
+			errCheck(err, "Failed to marshal keystore")
+
+			// Name the file after the account's real derivation path when known, falling back to the
+			// keystore's UUID when the account has no fixed path (e.g. a non-deterministic wallet).
+			pathComponent := keystoreUUID
+			if path != "" {
+				pathComponent = strings.ReplaceAll(path, "/", "_")
+			}
+			filename := filepath.Join(validatorExportKeystoreOutDir, fmt.Sprintf("keystore-%s-%d.json", pathComponent, timestamp))
+			errCheck(ioutil.WriteFile(filename, data, 0600), fmt.Sprintf("Failed to write %s", filename))
+			outputIf(debug, fmt.Sprintf("Wrote %s", filename))
+
+			depositData := struct {
+				PubKey                []byte `ssz-size:"48"`
+				WithdrawalCredentials []byte `ssz-size:"32"`
+				Value                 uint64
+			}{
+				PubKey:                privateKey.PublicKey().Marshal(),
+				WithdrawalCredentials: withdrawalCredentials,
+				Value:                 val,
+			}
+			domain := e2types.Domain(e2types.DomainDeposit, forkVersion, e2types.ZeroGenesisValidatorsRoot)
+			signature, err := signStruct(validatorAccount, depositData, domain)
+			errCheck(err, "Failed to generate deposit data signature")
+
+			signedDepositData := struct {
+				PubKey                []byte `ssz-size:"48"`
+				WithdrawalCredentials []byte `ssz-size:"32"`
+				Value                 uint64
+				Signature             []byte `ssz-size:"96"`
+			}{
+				PubKey:                privateKey.PublicKey().Marshal(),
+				WithdrawalCredentials: withdrawalCredentials,
+				Value:                 val,
+				Signature:             signature.Marshal(),
+			}
+			depositDataRoot, err := ssz.HashTreeRoot(signedDepositData)
+			errCheck(err, "Failed to generate deposit data root")
+			depositMessageRoot, err := ssz.HashTreeRoot(depositData)
+			errCheck(err, "Failed to generate deposit message root")
+
+			depositDataOutputs = append(depositDataOutputs, launchpadEntryJSON(signedDepositData.PubKey, signedDepositData.WithdrawalCredentials, val, signedDepositData.Signature, depositMessageRoot, depositDataRoot, forkVersion))
+		}
+
+		depositDataFilename := filepath.Join(validatorExportKeystoreOutDir, fmt.Sprintf("deposit_data-%d.json", timestamp))
+		depositDataJSON := fmt.Sprintf("[%s]", strings.Join(depositDataOutputs, ","))
+		errCheck(ioutil.WriteFile(depositDataFilename, []byte(depositDataJSON), 0600), fmt.Sprintf("Failed to write %s", depositDataFilename))
+		outputIf(debug, fmt.Sprintf("Wrote %s", depositDataFilename))
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		outputIf(!quiet, fmt.Sprintf("Exported %d keystore(s) to %s", len(validatorAccounts), validatorExportKeystoreOutDir))
+	},
+}
+
+// exportKeystoreEncryptor returns the EIP-2335 encryptor for the given KDF name, defaulting to scrypt.
+func exportKeystoreEncryptor(kdf string) (e2wtypes.Encryptor, error) {
+	switch strings.ToLower(kdf) {
+	case "", "scrypt":
+		return keystorev4.New(keystorev4.WithCipher("scrypt")), nil
+	case "pbkdf2":
+		return keystorev4.New(keystorev4.WithCipher("pbkdf2")), nil
+	default:
+		return nil, fmt.Errorf("unknown KDF %q", kdf)
+	}
+}
+
+// withdrawalCredentialsFromInput derives BLS withdrawal credentials from either a withdrawal account or public key.
+func withdrawalCredentialsFromInput(ctx context.Context, withdrawalAccount string, withdrawalPubKey string) ([]byte, error) {
+	assert(withdrawalAccount != "" || withdrawalPubKey != "", "--withdrawalaccount or --withdrawalpubkey is required")
+
+	var withdrawalCredentials []byte
+	if withdrawalAccount != "" {
+		_, account, err := walletAndAccountFromPath(ctx, withdrawalAccount)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := bestPublicKey(account)
+		if err != nil {
+			return nil, err
+		}
+		withdrawalCredentials = util.SHA256(pubKey.Marshal())
+	} else {
+		withdrawalPubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(withdrawalPubKey, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		assert(len(withdrawalPubKeyBytes) == 48, "Public key should be 48 bytes")
+		pubKey, err := e2types.BLSPublicKeyFromBytes(withdrawalPubKeyBytes)
+		if err != nil {
+			return nil, err
+		}
+		withdrawalCredentials = util.SHA256(pubKey.Marshal())
+	}
+	withdrawalCredentials[0] = byte(0) // BLS_WITHDRAWAL_PREFIX
+	return withdrawalCredentials, nil
+}
+
+// forkVersionFromInput returns a fork version from a hex string, falling back to a connected beacon node.
+func forkVersionFromInput(input string) ([]byte, error) {
+	if input != "" {
+		forkVersion, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		assert(len(forkVersion) == 4, "Fork version must be exactly four bytes")
+		return forkVersion, nil
+	}
+
+	err := connect()
+	if err != nil {
+		return nil, err
+	}
+	config, err := grpc.FetchChainConfig(eth2GRPCConn)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to beacon node; supply a connection with --connection or provide a fork version with --forkversion")
+	}
+	genesisForkVersion, exists := config["GenesisForkVersion"]
+	assert(exists, "Failed to obtain genesis fork version")
+	return genesisForkVersion.([]byte), nil
+}
+
+func init() {
+	validatorCmd.AddCommand(validatorExportKeystoreCmd)
+	validatorFlags(validatorExportKeystoreCmd)
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreValidatorAccount, "validatoraccount", "", "Account of the account carrying out the validation")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreWithdrawalAccount, "withdrawalaccount", "", "Account of the account to which the validator funds will be withdrawn")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreWithdrawalPubKey, "withdrawalpubkey", "", "Public key of the account to which the validator funds will be withdrawn")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreDepositValue, "depositvalue", "", "Value of the amount to be deposited")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreForkVersion, "forkversion", "", "Use a hard-coded fork version (default is to fetch it from the node)")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreKDF, "kdf", "scrypt", "Key derivation function to use for the keystores (scrypt or pbkdf2)")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystorePassphrase, "passphrase", "", "Passphrase with which to encrypt the keystores")
+	validatorExportKeystoreCmd.Flags().StringVar(&validatorExportKeystoreOutDir, "out-dir", "", "Directory to which to write the keystores and deposit data")
+}
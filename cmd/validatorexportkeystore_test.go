@@ -0,0 +1,62 @@
+// Copyright © 2021 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestExportKeystoreEncryptorDefaultsToScrypt(t *testing.T) {
+	defaultEncryptor, err := exportKeystoreEncryptor("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scryptEncryptor, err := exportKeystoreEncryptor("scrypt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secret := []byte("some secret bytes")
+	defaultFields, err := defaultEncryptor.Encrypt(secret, "passphrase")
+	if err != nil {
+		t.Fatalf("unexpected error encrypting with default KDF: %v", err)
+	}
+	scryptFields, err := scryptEncryptor.Encrypt(secret, "passphrase")
+	if err != nil {
+		t.Fatalf("unexpected error encrypting with explicit scrypt KDF: %v", err)
+	}
+	if defaultFields["kdf"].(map[string]interface{})["function"] != scryptFields["kdf"].(map[string]interface{})["function"] {
+		t.Errorf("default KDF should match explicit scrypt KDF")
+	}
+}
+
+func TestExportKeystoreEncryptorPBKDF2(t *testing.T) {
+	encryptor, err := exportKeystoreEncryptor("pbkdf2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fields, err := encryptor.Encrypt([]byte("some secret bytes"), "passphrase")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fields["kdf"].(map[string]interface{})["function"] != "pbkdf2" {
+		t.Errorf("expected pbkdf2 KDF, got %v", fields["kdf"].(map[string]interface{})["function"])
+	}
+}
+
+func TestExportKeystoreEncryptorUnknown(t *testing.T) {
+	if _, err := exportKeystoreEncryptor("argon2"); err == nil {
+		t.Errorf("expected an error for an unknown KDF")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2021 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+var validatorImportKeystoreDir string
+var validatorImportKeystorePassphrase string
+var validatorImportKeystoreWallet string
+var validatorImportKeystoreAccountPassphrase string
+
+var validatorImportKeystoreCmd = &cobra.Command{
+	Use:   "importkeystore",
+	Short: "Import validator accounts from EIP-2335 keystores",
+	Long: `Import one or more EIP-2335 keystores, as produced by "ethdo validator exportkeystore" or any other
+compliant tool, into a wallet. For example:
+
+    ethdo validator importkeystore --dir=./export --passphrase=secret --wallet=primary --accountpassphrase=secret2
+
+Every keystore-*.json file found in --dir is decrypted with --passphrase and re-imported as an account of
+--wallet, protected by --accountpassphrase.
+
+In quiet mode this will return 0 if the keystores can be imported correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorImportKeystoreDir != "", "--dir is required")
+		assert(validatorImportKeystorePassphrase != "", "--passphrase is required")
+		assert(validatorImportKeystoreWallet != "", "--wallet is required")
+		assert(validatorImportKeystoreAccountPassphrase != "", "--accountpassphrase is required")
+
+		wallet, err := walletFromPath(ctx, validatorImportKeystoreWallet)
+		errCheck(err, "Failed to obtain wallet")
+
+		files, err := ioutil.ReadDir(validatorImportKeystoreDir)
+		errCheck(err, "Failed to read --dir")
+
+		imported := 0
+		for _, file := range files {
+			if file.IsDir() || !strings.HasPrefix(file.Name(), "keystore-") || !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+
+			filename := filepath.Join(validatorImportKeystoreDir, file.Name())
+			data, err := ioutil.ReadFile(filename)
+			errCheck(err, fmt.Sprintf("Failed to read %s", filename))
+
+			keystore := make(map[string]interface{})
+			errCheck(json.Unmarshal(data, &keystore), fmt.Sprintf("Failed to parse %s", filename))
+
+			cryptoFields, ok := keystore["crypto"].(map[string]interface{})
+			assert(ok, fmt.Sprintf("%s does not contain a crypto section", filename))
+
+			encryptor := keystorev4.New()
+			secretKeyBytes, err := encryptor.Decrypt(cryptoFields, validatorImportKeystorePassphrase)
+			errCheck(err, fmt.Sprintf("Failed to decrypt %s", filename))
+
+			privateKey, err := e2types.BLSPrivateKeyFromBytes(secretKeyBytes)
+			errCheck(err, fmt.Sprintf("%s does not contain a valid private key", filename))
+
+			name := fmt.Sprintf("%x", privateKey.PublicKey().Marshal())
+			if description, exists := keystore["description"].(string); exists && description != "" {
+				name = description
+			}
+
+			creator, isAccountCreator := wallet.(e2wtypes.WalletAccountImporter)
+			assert(isAccountCreator, "Wallet does not support importing accounts")
+			_, err = creator.ImportAccount(ctx, name, privateKey.Marshal(), []byte(validatorImportKeystoreAccountPassphrase))
+			errCheck(err, fmt.Sprintf("Failed to import account from %s", filename))
+
+			outputIf(verbose, fmt.Sprintf("Imported %s as %s/%s", filename, wallet.Name(), name))
+			imported++
+		}
+
+		assert(imported > 0, fmt.Sprintf("No keystore-*.json files found in %s", validatorImportKeystoreDir))
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		outputIf(!quiet, fmt.Sprintf("Imported %d keystore(s) in to %s", imported, wallet.Name()))
+	},
+}
+
+func init() {
+	validatorCmd.AddCommand(validatorImportKeystoreCmd)
+	validatorFlags(validatorImportKeystoreCmd)
+	validatorImportKeystoreCmd.Flags().StringVar(&validatorImportKeystoreDir, "dir", "", "Directory containing the keystore-*.json files to import")
+	validatorImportKeystoreCmd.Flags().StringVar(&validatorImportKeystorePassphrase, "passphrase", "", "Passphrase with which the keystores are encrypted")
+	validatorImportKeystoreCmd.Flags().StringVar(&validatorImportKeystoreWallet, "wallet", "", "Wallet in to which the accounts will be imported")
+	validatorImportKeystoreCmd.Flags().StringVar(&validatorImportKeystoreAccountPassphrase, "accountpassphrase", "", "Passphrase with which to protect the imported accounts")
+}
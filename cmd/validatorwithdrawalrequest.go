@@ -0,0 +1,133 @@
+// Copyright © 2024 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var validatorWithdrawalRequestValidatorPubKey string
+var validatorWithdrawalRequestSourceAccount string
+var validatorWithdrawalRequestAmount string
+var validatorWithdrawalRequestRaw bool
+var validatorWithdrawalRequestELConnection string
+
+// validatorWithdrawalRequestPredeployAddress is the address of the EIP-7002 withdrawal request predeploy
+// contract on mainnet and the testnets that have activated Prague/Electra.
+const validatorWithdrawalRequestPredeployAddress = "0x00000961ef480eb55e80d19ad83579a64c007002"
+
+var validatorWithdrawalRequestCmd = &cobra.Command{
+	Use:   "withdrawalrequest",
+	Short: "Generate an EIP-7002 execution-layer withdrawal request",
+	Long: `Generate the calldata for an EIP-7002 withdrawal request, to be sent as a transaction to the
+withdrawal request predeploy contract. For example:
+
+    ethdo validator withdrawalrequest --validatorpubkey=0x... --amount=0
+
+An --amount of 0 requests a full exit; any other value (in Gwei) requests a partial withdrawal of that
+amount.
+
+In quiet mode this will return 0 if the request can be generated correctly, otherwise 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		assert(validatorWithdrawalRequestValidatorPubKey != "", "--validatorpubkey is required")
+		pubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(validatorWithdrawalRequestValidatorPubKey, "0x"))
+		errCheck(err, "Invalid --validatorpubkey")
+		assert(len(pubKeyBytes) == 48, "Validator public key should be 48 bytes")
+
+		source := ""
+		if validatorWithdrawalRequestSourceAccount != "" {
+			assert(common.IsHexAddress(validatorWithdrawalRequestSourceAccount), "--sourceaccount is not a valid execution address")
+			source = common.HexToAddress(validatorWithdrawalRequestSourceAccount).Hex()
+		}
+
+		assert(validatorWithdrawalRequestAmount != "", "--amount is required")
+		amount, err := amountFromInput(validatorWithdrawalRequestAmount)
+		errCheck(err, "Invalid --amount")
+
+		payload := make([]byte, 56)
+		copy(payload[0:48], pubKeyBytes)
+		binary.BigEndian.PutUint64(payload[48:56], amount)
+		outputIf(debug, fmt.Sprintf("Withdrawal request payload is %#x", payload))
+
+		if validatorWithdrawalRequestELConnection != "" {
+			fee, err := fetchWithdrawalRequestFee(ctx, validatorWithdrawalRequestELConnection)
+			errCheck(err, "Failed to fetch withdrawal request fee")
+			outputIf(!quiet, fmt.Sprintf("Current withdrawal request fee is %d wei", fee))
+		}
+
+		if quiet {
+			os.Exit(0)
+		}
+
+		switch {
+		case validatorWithdrawalRequestRaw:
+			fmt.Printf("%#x\n", payload)
+		default:
+			fmt.Printf(`{"source_address":"%s","validator_pubkey":"%#x","amount":%d}`+"\n", source, pubKeyBytes, amount)
+		}
+	},
+}
+
+// amountFromInput parses a withdrawal amount in Gwei, where 0 signifies a full exit.
+func amountFromInput(input string) (uint64, error) {
+	return strconv.ParseUint(input, 10, 64)
+}
+
+// fetchWithdrawalRequestFee queries the withdrawal request predeploy for its current fee, in wei, via
+// an execution-layer JSON-RPC endpoint. Per EIP-7002, the fee is returned as the big-endian encoding of
+// the contract's balance-based fee schedule from a call to the predeploy with empty calldata.
+func fetchWithdrawalRequestFee(ctx context.Context, elConnection string) (uint64, error) {
+	client, err := ethclient.DialContext(ctx, elConnection)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	predeployAddress := common.HexToAddress(validatorWithdrawalRequestPredeployAddress)
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &predeployAddress}, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, fmt.Errorf("withdrawal request predeploy returned no data")
+	}
+
+	return new(big.Int).SetBytes(result).Uint64(), nil
+}
+
+func init() {
+	validatorCmd.AddCommand(validatorWithdrawalRequestCmd)
+	validatorFlags(validatorWithdrawalRequestCmd)
+	validatorWithdrawalRequestCmd.Flags().StringVar(&validatorWithdrawalRequestValidatorPubKey, "validatorpubkey", "", "Public key of the validator to withdraw or exit")
+	validatorWithdrawalRequestCmd.Flags().StringVar(&validatorWithdrawalRequestSourceAccount, "sourceaccount", "", "Account expected to pay the withdrawal request fee")
+	validatorWithdrawalRequestCmd.Flags().StringVar(&validatorWithdrawalRequestAmount, "amount", "0", "Amount to withdraw in Gwei (0 requests a full exit)")
+	validatorWithdrawalRequestCmd.Flags().BoolVar(&validatorWithdrawalRequestRaw, "raw", false, "Print raw withdrawal request calldata")
+	validatorWithdrawalRequestCmd.Flags().StringVar(&validatorWithdrawalRequestELConnection, "el-connection", "", "Execution layer RPC endpoint from which to fetch the current withdrawal request fee")
+}
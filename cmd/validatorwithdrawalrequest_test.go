@@ -0,0 +1,50 @@
+// Copyright © 2024 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestAmountFromInputFullExit(t *testing.T) {
+	amount, err := amountFromInput("0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if amount != 0 {
+		t.Errorf("expected 0, got %d", amount)
+	}
+}
+
+func TestAmountFromInputPartialWithdrawal(t *testing.T) {
+	amount, err := amountFromInput("32000000000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if amount != 32000000000 {
+		t.Errorf("expected 32000000000, got %d", amount)
+	}
+}
+
+func TestAmountFromInputInvalid(t *testing.T) {
+	if _, err := amountFromInput("not a number"); err == nil {
+		t.Errorf("expected an error for a non-numeric amount")
+	}
+}
+
+func TestAmountFromInputNegative(t *testing.T) {
+	if _, err := amountFromInput("-1"); err == nil {
+		t.Errorf("expected an error for a negative amount")
+	}
+}